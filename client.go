@@ -1,50 +1,200 @@
 package main
 
 import (
-	"sync"
-	"io/ioutil"
+	"bytes"
+	"context"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
-	"flag"
+	"os"
+	"os/signal"
+	"sync"
 	"time"
 )
 
-func make_requests(count int, waitGroup *sync.WaitGroup) {
-	for i := 0 ; i < count; i++ {
-		response, err := http.Get("http://localhost:8888")
-		if err != nil {
-			fmt.Println("Got error message: " + err.Error())
-		} else {
-			_, err := ioutil.ReadAll(response.Body)
-			if err != nil {
-				fmt.Print("Unexpected error while reading body: " + err.Error())
+// newClient builds a single http.Client backed by a tuned Transport so that
+// all workers share connection pooling instead of paying a fresh dial (and a
+// fresh ephemeral port) per request.
+func newClient(maxConnsPerHost int, maxIdleConnsPerHost int, idleConnTimeout time.Duration, disableKeepAlives bool) *http.Client {
+	transport := &http.Transport{
+		MaxConnsPerHost:     maxConnsPerHost,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeout,
+		DisableKeepAlives:   disableKeepAlives,
+	}
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
+// doRequest issues a single attempt of endpoint's request, honoring the
+// endpoint's rate/concurrency limiter for the duration of the call.
+func doRequest(client *http.Client, endpoint *Endpoint, headers http.Header, limiter *endpointLimiter) (statusCode int, bytesRead int64, err error) {
+	var bodyReader *bytes.Reader
+	if endpoint.Body != nil {
+		bodyReader = bytes.NewReader(endpoint.Body)
+	} else {
+		bodyReader = bytes.NewReader(nil)
+	}
+	request, err := http.NewRequest(endpoint.Method, endpoint.URL, bodyReader)
+	if err != nil {
+		return 0, 0, err
+	}
+	for key, values := range headers {
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+
+	limiter.acquire()
+	defer limiter.release()
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, 0, err
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return response.StatusCode, 0, err
+	}
+	return response.StatusCode, int64(len(body)), nil
+}
+
+// worker pulls jobs off the jobs channel until it is closed. For each job it
+// picks an endpoint from workload and issues the request, retrying it
+// according to policy, and reports a single Result on results. Each attempt
+// (including retries) is throttled by limiters, so a retry storm cannot
+// exceed the configured QPS or concurrency caps.
+func worker(client *http.Client, workload *Workload, headers http.Header, limiters *limiterRegistry, policy *retryPolicy, jobs <-chan struct{}, results chan<- Result, waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+	for range jobs {
+		endpoint := workload.pick()
+		limiter := limiters.get(endpoint.URL)
+		start := time.Now()
+
+		var statusCode int
+		var bytesRead int64
+		var err error
+		attempt := 0
+		for {
+			statusCode, bytesRead, err = doRequest(client, endpoint, headers, limiter)
+			if !policy.shouldRetry(statusCode, err) || attempt >= policy.maxRetries {
+				break
 			}
-			response.Body.Close()
+			time.Sleep(policy.delay(attempt))
+			attempt++
 		}
+
+		results <- Result{Duration: time.Since(start), StatusCode: statusCode, Bytes: bytesRead, Err: err, Retries: attempt}
 	}
-	waitGroup.Done()
 }
 
 func main() {
-	threadCount := flag.Int("threads", 10, "Number of threads to run concurrently")
-	requestCount := flag.Int("requests", 1, "Number of requests per thread")
+	requestCount := flag.Int("n", 10, "Total number of requests to issue")
+	concurrency := flag.Int("concurrency", 10, "Number of requests allowed in flight at the same time")
+	maxConnsPerHost := flag.Int("max-conns-per-host", 0, "Maximum number of connections per host (0 = no limit)")
+	maxIdleConnsPerHost := flag.Int("max-idle-conns-per-host", 100, "Maximum number of idle keep-alive connections per host")
+	idleConnTimeout := flag.Duration("idle-conn-timeout", 90*time.Second, "How long an idle connection is kept alive before being closed")
+	disableKeepAlives := flag.Bool("disable-keep-alives", false, "Disable HTTP keep-alives, forcing a new connection per request")
+	rate := flag.Int("rate", 0, "Global requests/sec cap per endpoint (0 = unlimited)")
+	rateFor := flag.String("rate-for", "", "Per-endpoint requests/sec caps, e.g. http://host/a=10,http://host/b=20")
+	endpointConcurrency := flag.Int("endpoint-concurrency", 0, "Max concurrent in-flight requests per endpoint (0 = bounded only by -concurrency)")
+	urlsFile := flag.String("urls", "", "File of weighted endpoints to draw requests from, one per line (\"url\" or \"weight verb url\")")
+	bodySpec := flag.String("body", "", "Request body for POST/PUT endpoints; use @file to read it from a file")
+	headers := newHeaderList()
+	flag.Var(headers, "H", "Custom request header \"K: V\"; may be repeated")
+	maxRetries := flag.Int("retries", 0, "Number of times to retry a request that fails the -retry-on policy")
+	retryOn := flag.String("retry-on", "5xx,timeout,connreset", "Comma-separated failure kinds that are retried: 5xx,timeout,connreset,connrefused,dns,tls")
+	retryBackoff := flag.Duration("retry-backoff", 100*time.Millisecond, "Base delay before a retry, doubled on each subsequent attempt plus jitter")
+	duration := flag.Duration("duration", 0, "Run for this long instead of a fixed request count, ramping the dispatch rate per -ramp-up/-ramp-down/-target-rate")
+	rampUp := flag.Duration("ramp-up", 0, "Linearly ramp the dispatch rate from 0 to -target-rate over this long")
+	rampDown := flag.Duration("ramp-down", 0, "Linearly ramp the dispatch rate from -target-rate to 0 over this long at the end of the run")
+	targetRate := flag.Int("target-rate", 0, "Dispatch rate (requests/sec) to ramp to and hold during a -duration run")
 	flag.Parse()
-	fmt.Println("Using", *threadCount, "threads and", *requestCount, "requests per thread")
+
+	rates, err := parseRateFor(*rateFor)
+	if err != nil {
+		fmt.Println("Invalid -rate-for:", err.Error())
+		return
+	}
+
+	body, err := loadBody(*bodySpec)
+	if err != nil {
+		fmt.Println("Invalid -body:", err.Error())
+		return
+	}
+
+	var workload *Workload
+	if *urlsFile != "" {
+		workload, err = loadWorkloadFile(*urlsFile, body)
+	} else {
+		workload = newWorkload([]Endpoint{{Weight: 1, Method: http.MethodGet, URL: "http://localhost:8888", Body: body}})
+	}
+	if err != nil {
+		fmt.Println("Invalid -urls:", err.Error())
+		return
+	}
+
+	client := newClient(*maxConnsPerHost, *maxIdleConnsPerHost, *idleConnTimeout, *disableKeepAlives)
+	limiters := newLimiterRegistry(*rate, *endpointConcurrency, *concurrency, rates)
+	defer limiters.CloseAll()
+	policy := &retryPolicy{maxRetries: *maxRetries, backoff: *retryBackoff, retryOn: parseRetryOn(*retryOn)}
+
+	jobs := make(chan struct{}, *concurrency)
+	results := make(chan Result, *concurrency)
 	var waitGroup sync.WaitGroup
-	waitGroup.Add(*threadCount)
+	waitGroup.Add(*concurrency)
+
+	counters := &liveCounters{}
+	statsDone := make(chan *Stats)
+	go func() {
+		statsDone <- collect(results, counters)
+	}()
 
 	start := time.Now()
-	for i := 0; i < *threadCount; i++ {
-		go make_requests(*requestCount, &waitGroup)
+	for i := 0; i < *concurrency; i++ {
+		go worker(client, workload, headers.Header, limiters, policy, jobs, results, &waitGroup)
 	}
+
+	if *duration > 0 {
+		fmt.Println("Running for", *duration, "ramping up over", *rampUp, "to", *targetRate, "req/s")
+		ctx, cancel := context.WithCancel(context.Background())
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt)
+		go func() {
+			<-sigCh
+			fmt.Println("Received interrupt, draining in-flight requests...")
+			cancel()
+		}()
+		profile := &rampProfile{duration: *duration, rampUp: *rampUp, rampDown: *rampDown, targetRate: *targetRate}
+		stopProgress := make(chan struct{})
+		go reportProgress(counters, stopProgress)
+		dispatch(ctx, profile, jobs, &counters.dispatched)
+		close(stopProgress)
+		signal.Stop(sigCh)
+		cancel()
+	} else {
+		fmt.Println("Issuing", *requestCount, "requests with concurrency", *concurrency)
+		for i := 0; i < *requestCount; i++ {
+			jobs <- struct{}{}
+		}
+		close(jobs)
+	}
+
 	waitGroup.Wait()
+	close(results)
+	stats := <-statsDone
 	elapsed := time.Since(start)
+
+	completed := counters.completed
 	seconds := elapsed.Seconds()
-	count := *threadCount * *requestCount
-	fmt.Println("Did ", count, "requests in ", seconds, "seconds")
 	perSeconds := 0.0
 	if seconds > 0 {
-		perSeconds = float64(count) / seconds
+		perSeconds = float64(completed) / seconds
 	}
+	fmt.Println("Did ", completed, "requests in ", seconds, "seconds")
 	fmt.Println("Rate:", perSeconds, "requests / second")
-}
\ No newline at end of file
+	stats.Report(elapsed)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// rampProfile describes a time-bounded run that ramps the dispatch rate up
+// to targetRate, holds it, and optionally ramps back down at the end.
+type rampProfile struct {
+	duration   time.Duration
+	rampUp     time.Duration
+	rampDown   time.Duration
+	targetRate int
+}
+
+// rateAt returns the dispatch rate (requests/sec) that should be in effect
+// at elapsed time into the run.
+func (p *rampProfile) rateAt(elapsed time.Duration) int {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	switch {
+	case p.rampUp > 0 && elapsed < p.rampUp:
+		fraction := float64(elapsed) / float64(p.rampUp)
+		return int(fraction * float64(p.targetRate))
+	case p.rampDown > 0 && elapsed > p.duration-p.rampDown:
+		remaining := p.duration - elapsed
+		if remaining < 0 {
+			remaining = 0
+		}
+		fraction := float64(remaining) / float64(p.rampDown)
+		return int(fraction * float64(p.targetRate))
+	default:
+		return p.targetRate
+	}
+}
+
+// dispatchTickInterval is how often the dispatcher recomputes the current
+// target rate and enqueues jobs to match it.
+const dispatchTickInterval = 100 * time.Millisecond
+
+// dispatch feeds jobs according to profile until ctx is cancelled or the
+// profile's duration elapses, then closes jobs. dispatched is incremented
+// for every job enqueued, so a progress reporter can derive in-flight count.
+func dispatch(ctx context.Context, profile *rampProfile, jobs chan<- struct{}, dispatched *int64) {
+	defer close(jobs)
+	ticker := time.NewTicker(dispatchTickInterval)
+	defer ticker.Stop()
+	start := time.Now()
+	var carry float64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			elapsed := now.Sub(start)
+			if elapsed >= profile.duration {
+				return
+			}
+			rate := profile.rateAt(elapsed)
+			carry += float64(rate) * dispatchTickInterval.Seconds()
+			n := int(carry)
+			carry -= float64(n)
+			for i := 0; i < n; i++ {
+				select {
+				case jobs <- struct{}{}:
+					atomic.AddInt64(dispatched, 1)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// liveCounters tracks running totals so a ramp run can report progress
+// before the final Stats are available.
+type liveCounters struct {
+	dispatched int64
+	completed  int64
+	errors     int64
+}
+
+// reportProgress prints one line per second with the rate achieved since the
+// last tick, the number of requests still in flight, and the error count,
+// until stop is closed.
+func reportProgress(counters *liveCounters, stop <-chan struct{}) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	var lastCompleted int64
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			completed := atomic.LoadInt64(&counters.completed)
+			dispatched := atomic.LoadInt64(&counters.dispatched)
+			errors := atomic.LoadInt64(&counters.errors)
+			fmt.Println("progress: rate", completed-lastCompleted, "req/s, in-flight", dispatched-completed, ", errors", errors)
+			lastCompleted = completed
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRampProfileRateAt(t *testing.T) {
+	p := &rampProfile{
+		duration:   20 * time.Second,
+		rampUp:     10 * time.Second,
+		rampDown:   5 * time.Second,
+		targetRate: 100,
+	}
+
+	cases := []struct {
+		elapsed time.Duration
+		want    int
+	}{
+		{0, 0},
+		{5 * time.Second, 50},
+		{10 * time.Second, 100},
+		{14 * time.Second, 100},
+		{16 * time.Second, 80},
+		{20 * time.Second, 0},
+	}
+	for _, c := range cases {
+		if got := p.rateAt(c.elapsed); got != c.want {
+			t.Errorf("rateAt(%v) = %d, want %d", c.elapsed, got, c.want)
+		}
+	}
+}
+
+func TestRampProfileNoRamps(t *testing.T) {
+	p := &rampProfile{duration: 10 * time.Second, targetRate: 200}
+	if got := p.rateAt(0); got != 200 {
+		t.Errorf("rateAt(0) = %d, want 200 when no ramp-up is configured", got)
+	}
+	if got := p.rateAt(5 * time.Second); got != 200 {
+		t.Errorf("rateAt(5s) = %d, want 200", got)
+	}
+}
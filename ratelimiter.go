@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpointLimiter bounds the request rate and the concurrent in-flight
+// requests for a single endpoint. tokens is refilled at a fixed rate and
+// must be drained before a request may start; sem is a separate cap on how
+// many requests against this endpoint may be outstanding at once, since a
+// burst that stays under the rate limit should still be bounded.
+type endpointLimiter struct {
+	tokens chan struct{}
+	sem    chan struct{}
+	stop   chan struct{}
+}
+
+// newEndpointLimiter creates a limiter for one endpoint. A rate of 0 means
+// no rate limiting is applied. concurrency must already be resolved to a
+// positive value by the caller (callers that want "bounded only by the
+// overall worker pool" pass the pool's own -concurrency size).
+func newEndpointLimiter(rate int, concurrency int) *endpointLimiter {
+	limiter := &endpointLimiter{
+		sem:  make(chan struct{}, concurrency),
+		stop: make(chan struct{}),
+	}
+	if rate > 0 {
+		limiter.tokens = make(chan struct{}, rate)
+		go limiter.refill(rate)
+	}
+	return limiter
+}
+
+// refill adds one token per tick until the limiter is stopped, dropping the
+// token if the bucket is already full.
+func (l *endpointLimiter) refill(rate int) {
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			select {
+			case l.tokens <- struct{}{}:
+			default:
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// acquire blocks until both a rate token and a concurrency slot are
+// available for this endpoint.
+func (l *endpointLimiter) acquire() {
+	if l.tokens != nil {
+		<-l.tokens
+	}
+	l.sem <- struct{}{}
+}
+
+// release frees the concurrency slot taken by acquire.
+func (l *endpointLimiter) release() {
+	<-l.sem
+}
+
+func (l *endpointLimiter) Close() {
+	close(l.stop)
+}
+
+// limiterRegistry hands out a per-endpoint limiter, falling back to
+// defaultRate and defaultConcurrency for endpoints without an override.
+// limiters is read and written from every worker goroutine, so access is
+// serialized by mu.
+type limiterRegistry struct {
+	mu                 sync.Mutex
+	limiters           map[string]*endpointLimiter
+	rates              map[string]int
+	defaultRate        int
+	defaultConcurrency int
+	poolConcurrency    int
+}
+
+// newLimiterRegistry builds a registry. defaultConcurrency <= 0 means an
+// endpoint's in-flight cap falls back to poolConcurrency (the overall
+// worker pool size), i.e. the endpoint is only bounded by -concurrency.
+func newLimiterRegistry(defaultRate int, defaultConcurrency int, poolConcurrency int, rates map[string]int) *limiterRegistry {
+	return &limiterRegistry{
+		limiters:           make(map[string]*endpointLimiter),
+		rates:              rates,
+		defaultRate:        defaultRate,
+		defaultConcurrency: defaultConcurrency,
+		poolConcurrency:    poolConcurrency,
+	}
+}
+
+// get returns the limiter for url, creating it on first use.
+func (r *limiterRegistry) get(url string) *endpointLimiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if limiter, ok := r.limiters[url]; ok {
+		return limiter
+	}
+	rate := r.defaultRate
+	if override, ok := r.rates[url]; ok {
+		rate = override
+	}
+	concurrency := r.defaultConcurrency
+	if concurrency <= 0 {
+		concurrency = r.poolConcurrency
+	}
+	limiter := newEndpointLimiter(rate, concurrency)
+	r.limiters[url] = limiter
+	return limiter
+}
+
+func (r *limiterRegistry) CloseAll() {
+	for _, limiter := range r.limiters {
+		limiter.Close()
+	}
+}
+
+// parseRateFor parses a comma-separated list of url=rate pairs, as accepted
+// by the -rate-for flag, e.g. "http://host/a=10,http://host/b=20".
+func parseRateFor(spec string) (map[string]int, error) {
+	rates := make(map[string]int)
+	if spec == "" {
+		return rates, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid -rate-for entry %q, expected url=rate", pair)
+		}
+		rate, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		rates[parts[0]] = rate
+	}
+	return rates, nil
+}
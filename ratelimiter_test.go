@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestLimiterRegistryDefaultConcurrencyFallsBackToPool guards against the
+// regression where defaultConcurrency <= 0 was clamped to 1 instead of
+// falling back to the overall worker pool size, which serialized every
+// request to an endpoint regardless of -concurrency.
+func TestLimiterRegistryDefaultConcurrencyFallsBackToPool(t *testing.T) {
+	const poolConcurrency = 20
+	r := newLimiterRegistry(0, 0, poolConcurrency, map[string]int{})
+	limiter := r.get("http://example.invalid")
+	if got := cap(limiter.sem); got != poolConcurrency {
+		t.Errorf("sem capacity = %d, want %d (poolConcurrency)", got, poolConcurrency)
+	}
+}
+
+func TestLimiterRegistryExplicitConcurrencyIsHonored(t *testing.T) {
+	r := newLimiterRegistry(0, 5, 20, map[string]int{})
+	limiter := r.get("http://example.invalid")
+	if got := cap(limiter.sem); got != 5 {
+		t.Errorf("sem capacity = %d, want 5 (defaultConcurrency)", got)
+	}
+}
+
+// TestLimiterRegistryGetConcurrent exercises limiterRegistry.get from many
+// goroutines at once, for different URLs and for the same URL, so that
+// `go test -race` catches any regression of the concurrent map read/write
+// bug that used to panic under real load.
+func TestLimiterRegistryGetConcurrent(t *testing.T) {
+	r := newLimiterRegistry(0, 0, 10, map[string]int{})
+	urls := []string{"http://a", "http://b", "http://c"}
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		waitGroup.Add(1)
+		url := urls[i%len(urls)]
+		go func() {
+			defer waitGroup.Done()
+			limiter := r.get(url)
+			limiter.acquire()
+			limiter.release()
+		}()
+	}
+	waitGroup.Wait()
+
+	if got := len(r.limiters); got != len(urls) {
+		t.Errorf("len(limiters) = %d, want %d (one per distinct url)", got, len(urls))
+	}
+}
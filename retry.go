@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// retryPolicy controls whether a failed attempt is retried and how long to
+// wait before the next attempt.
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+	retryOn    map[string]bool
+}
+
+// parseRetryOn parses the comma-separated -retry-on list, e.g.
+// "5xx,timeout,connreset".
+func parseRetryOn(spec string) map[string]bool {
+	retryOn := make(map[string]bool)
+	for _, kind := range strings.Split(spec, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind != "" {
+			retryOn[kind] = true
+		}
+	}
+	return retryOn
+}
+
+// shouldRetry decides whether an attempt that produced statusCode/err is
+// retryable under this policy. A nil err with a 2xx/3xx statusCode is never
+// retried.
+func (p *retryPolicy) shouldRetry(statusCode int, err error) bool {
+	if err != nil {
+		return p.retryOn[classifyError(err)]
+	}
+	if p.retryOn["5xx"] && statusCode >= 500 && statusCode < 600 {
+		return true
+	}
+	return false
+}
+
+// backoff computes the delay before retry attempt number attempt (0-based),
+// growing exponentially from the configured base with added jitter so
+// concurrent retries don't all land on the same tick.
+func (p *retryPolicy) delay(attempt int) time.Duration {
+	growth := time.Duration(1) << uint(attempt)
+	jitter := time.Duration(0)
+	if p.backoff > 0 {
+		jitter = time.Duration(rand.Int63n(int64(p.backoff) + 1))
+	}
+	return p.backoff*growth + jitter
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRetryOn(t *testing.T) {
+	got := parseRetryOn(" 5xx, timeout,connreset ")
+	want := []string{"5xx", "timeout", "connreset"}
+	for _, kind := range want {
+		if !got[kind] {
+			t.Errorf("parseRetryOn result missing %q: %v", kind, got)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("parseRetryOn returned %d kinds, want %d: %v", len(got), len(want), got)
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	p := &retryPolicy{retryOn: parseRetryOn("5xx,timeout,connreset")}
+
+	cases := []struct {
+		name       string
+		statusCode int
+		err        error
+		want       bool
+	}{
+		{"5xx is retried", 503, nil, true},
+		{"2xx is not retried", 200, nil, false},
+		{"4xx is not retried", 404, nil, false},
+		{"timeout error is retried", 0, timeoutError{}, true},
+		{"connreset error is retried", 0, errors.New("read: connection reset by peer"), true},
+		{"dns error is not in policy", 0, errors.New("lookup foo: no such host"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := p.shouldRetry(c.statusCode, c.err); got != c.want {
+				t.Errorf("shouldRetry(%d, %v) = %v, want %v", c.statusCode, c.err, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyShouldRetryEmptyPolicyRetriesNothing(t *testing.T) {
+	p := &retryPolicy{retryOn: parseRetryOn("")}
+	if p.shouldRetry(503, nil) {
+		t.Error("shouldRetry(503, nil) = true, want false when -retry-on is empty")
+	}
+	if p.shouldRetry(0, timeoutError{}) {
+		t.Error("shouldRetry with a timeout error = true, want false when -retry-on is empty")
+	}
+}
+
+func TestRetryPolicyDelayGrowsExponentially(t *testing.T) {
+	p := &retryPolicy{backoff: 100 * time.Millisecond}
+	for attempt := 0; attempt < 4; attempt++ {
+		base := p.backoff * time.Duration(uint64(1)<<uint(attempt))
+		max := base + p.backoff
+		d := p.delay(attempt)
+		if d < base || d > max {
+			t.Errorf("delay(%d) = %v, want in [%v, %v]", attempt, d, base, max)
+		}
+	}
+}
+
+func TestRetryPolicyDelayZeroBackoff(t *testing.T) {
+	p := &retryPolicy{backoff: 0}
+	if d := p.delay(3); d != 0 {
+		t.Errorf("delay(3) = %v, want 0 when backoff is 0", d)
+	}
+}
+
+// timeoutError implements net.Error with Timeout() == true, to exercise the
+// classifyError timeout path without depending on a real network call.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
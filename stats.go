@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Result is what a worker reports back for a single completed (or failed)
+// request. Retries counts additional attempts beyond the first that the
+// retry policy made before this Result was produced.
+type Result struct {
+	Duration   time.Duration
+	StatusCode int
+	Bytes      int64
+	Err        error
+	Retries    int
+}
+
+// The latency histogram uses an HdrHistogram-style layout: bucket 0 covers
+// values [0, subBucketCount) linearly (one slot per microsecond), and each
+// following bucket covers a range twice the width of the previous one,
+// still split into subBucketCount equal-width slots. That keeps relative
+// error bounded by 1/subBucketCount (~0.8% with 7 bits of sub-bucket
+// precision) regardless of magnitude, while memory stays fixed at
+// numBuckets*subBucketCount counters rather than growing with the number of
+// samples.
+const (
+	subBucketBits  = 7
+	subBucketCount = 1 << subBucketBits
+	numBuckets     = 34
+	histogramSize  = numBuckets * subBucketCount
+)
+
+// latencyHistogram is a fixed-size bucketed histogram used to estimate
+// latency quantiles without retaining every individual sample.
+type latencyHistogram struct {
+	counts [histogramSize]int64
+	count  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// bucketFor locates the (bucket, slot) a value of us microseconds falls
+// into, clamping to the top of the histogram's range if it overflows.
+func bucketFor(us int64) (bucket int, rangeStart int64, width int64) {
+	if us < subBucketCount {
+		return 0, 0, 1
+	}
+	bucket = 1
+	rangeStart = subBucketCount
+	width = 1
+	for bucket < numBuckets-1 && us >= rangeStart*2 {
+		rangeStart *= 2
+		width *= 2
+		bucket++
+	}
+	return bucket, rangeStart, width
+}
+
+// histogramIndex returns the flat counts[] slot for d.
+func histogramIndex(d time.Duration) int {
+	us := d.Microseconds()
+	if us < 0 {
+		us = 0
+	}
+	bucket, rangeStart, width := bucketFor(us)
+	pos := int((us - rangeStart) / width)
+	if pos >= subBucketCount {
+		pos = subBucketCount - 1
+	}
+	return bucket*subBucketCount + pos
+}
+
+// histogramUpperBound returns the inclusive upper bound, in microseconds,
+// of the value range represented by flat counts[] slot idx.
+func histogramUpperBound(idx int) int64 {
+	bucket := idx / subBucketCount
+	pos := idx % subBucketCount
+	if bucket == 0 {
+		return int64(pos)
+	}
+	rangeStart := int64(subBucketCount) << uint(bucket-1)
+	width := int64(1) << uint(bucket-1)
+	return rangeStart + int64(pos+1)*width - 1
+}
+
+func (h *latencyHistogram) add(d time.Duration) {
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+	h.counts[histogramIndex(d)]++
+}
+
+func (h *latencyHistogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// quantile returns an estimate of the q-th quantile (0 <= q <= 1), accurate
+// to the width of the bucket it falls into.
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(q * float64(h.count)))
+	var cumulative int64
+	for idx, c := range h.counts {
+		cumulative += c
+		if cumulative >= target {
+			bound := time.Duration(histogramUpperBound(idx)) * time.Microsecond
+			if bound > h.max {
+				bound = h.max
+			}
+			return bound
+		}
+	}
+	return h.max
+}
+
+// Stats aggregates the Results collected over the course of a run.
+type Stats struct {
+	latency       latencyHistogram
+	statusCounts  map[int]int64
+	errorCounts   map[string]int64
+	totalBytes    int64
+	successCount  int64
+	errorCount    int64
+	retriedCount  int64
+	retryAttempts int64
+}
+
+func newStats() *Stats {
+	return &Stats{
+		statusCounts: make(map[int]int64),
+		errorCounts:  make(map[string]int64),
+	}
+}
+
+// record folds one Result into the aggregate. A non-2xx response with no
+// transport error is still counted as an error, under the "non-2xx" kind.
+func (s *Stats) record(r Result) {
+	if r.Retries > 0 {
+		s.retriedCount++
+		s.retryAttempts += int64(r.Retries)
+	}
+	if r.Err != nil {
+		s.errorCount++
+		s.errorCounts[classifyError(r.Err)]++
+		return
+	}
+	s.latency.add(r.Duration)
+	s.statusCounts[r.StatusCode]++
+	s.totalBytes += r.Bytes
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		s.errorCount++
+		s.errorCounts["non-2xx"]++
+	} else {
+		s.successCount++
+	}
+}
+
+// classifyError buckets a transport-level error into a small taxonomy so the
+// final report can show a breakdown rather than a wall of distinct messages.
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "dns"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "connection refused"):
+		return "connrefused"
+	case strings.Contains(message, "connection reset"):
+		return "connreset"
+	case strings.Contains(message, "tls"), strings.Contains(message, "x509"):
+		return "tls"
+	default:
+		return "other"
+	}
+}
+
+// collect drains results until the channel is closed and returns the
+// aggregated Stats. counters, if non-nil, is updated alongside stats so a
+// progress reporter can observe completion and error totals live.
+func collect(results <-chan Result, counters *liveCounters) *Stats {
+	stats := newStats()
+	for r := range results {
+		stats.record(r)
+		if counters != nil {
+			atomic.AddInt64(&counters.completed, 1)
+			if r.Err != nil || r.StatusCode < 200 || r.StatusCode >= 300 {
+				atomic.AddInt64(&counters.errors, 1)
+			}
+		}
+	}
+	return stats
+}
+
+// Report prints the final min/mean/median/p90/p95/p99/max latency, a status
+// code histogram, byte-throughput, and an error-kind breakdown.
+func (s *Stats) Report(elapsed time.Duration) {
+	h := s.latency
+	fmt.Println("Latency min/mean/median/p90/p95/p99/max:",
+		h.min, "/", h.mean(), "/", h.quantile(0.5), "/", h.quantile(0.9), "/",
+		h.quantile(0.95), "/", h.quantile(0.99), "/", h.max)
+
+	fmt.Println("Status codes:")
+	codes := make([]int, 0, len(s.statusCounts))
+	for code := range s.statusCounts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Println(" ", code, ":", s.statusCounts[code])
+	}
+
+	seconds := elapsed.Seconds()
+	throughput := 0.0
+	if seconds > 0 {
+		throughput = float64(s.totalBytes) / (1024 * 1024) / seconds
+	}
+	fmt.Println("Throughput:", throughput, "MB/s")
+
+	completed := s.successCount + s.errorCount
+	fmt.Println("Requests: completed", completed, ", successful", s.successCount,
+		", retried", s.retriedCount, "(", s.retryAttempts, "retry attempts )")
+
+	if s.errorCount > 0 {
+		fmt.Println("Errors (", s.errorCount, "total ):")
+		kinds := make([]string, 0, len(s.errorCounts))
+		for kind := range s.errorCounts {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Println(" ", kind, ":", s.errorCounts[kind])
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestLatencyHistogramQuantiles checks quantile accuracy against a known
+// uniform distribution, which is what actually caught the original
+// low-resolution histogram (32 power-of-two buckets collapsed p90 and p99
+// into the same value).
+func TestLatencyHistogramQuantiles(t *testing.T) {
+	h := &latencyHistogram{}
+	for ms := 1; ms <= 1000; ms++ {
+		h.add(time.Duration(ms) * time.Millisecond)
+	}
+
+	cases := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, 500 * time.Millisecond},
+		{0.9, 900 * time.Millisecond},
+		{0.99, 990 * time.Millisecond},
+	}
+
+	const tolerance = 0.02 // 2% relative error
+	for _, c := range cases {
+		got := h.quantile(c.q)
+		diff := math.Abs(float64(got-c.want)) / float64(c.want)
+		if diff > tolerance {
+			t.Errorf("quantile(%v) = %v, want ~%v (diff %.4f > tolerance %.4f)", c.q, got, c.want, diff, tolerance)
+		}
+	}
+
+	if q90, q99 := h.quantile(0.9), h.quantile(0.99); q90 == q99 {
+		t.Errorf("quantile(0.9) and quantile(0.99) collapsed to the same value %v", q90)
+	}
+}
+
+func TestLatencyHistogramMinMaxMean(t *testing.T) {
+	h := &latencyHistogram{}
+	for _, ms := range []int{10, 20, 30} {
+		h.add(time.Duration(ms) * time.Millisecond)
+	}
+	if h.min != 10*time.Millisecond {
+		t.Errorf("min = %v, want 10ms", h.min)
+	}
+	if h.max != 30*time.Millisecond {
+		t.Errorf("max = %v, want 30ms", h.max)
+	}
+	if h.mean() != 20*time.Millisecond {
+		t.Errorf("mean = %v, want 20ms", h.mean())
+	}
+}
+
+func TestClassifyErrorNonNetwork(t *testing.T) {
+	if got := classifyError(errBoom{}); got != "other" {
+		t.Errorf("classifyError = %q, want \"other\"", got)
+	}
+}
+
+type errBoom struct{}
+
+func (errBoom) Error() string { return "boom" }
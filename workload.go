@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Endpoint is one target in a workload: a method/URL pair with an optional
+// body, weighted relative to the other endpoints in the same workload.
+type Endpoint struct {
+	Weight int
+	Method string
+	URL    string
+	Body   []byte
+}
+
+// Workload picks an Endpoint per request according to its weight, using a
+// precomputed cumulative-weight table so selection is O(log n) instead of a
+// linear scan per request.
+type Workload struct {
+	endpoints  []Endpoint
+	cumWeights []int64
+	total      int64
+}
+
+// newWorkload builds the cumulative-weight table for endpoints. Endpoints
+// with a weight <= 0 default to weight 1.
+func newWorkload(endpoints []Endpoint) *Workload {
+	w := &Workload{
+		endpoints:  endpoints,
+		cumWeights: make([]int64, len(endpoints)),
+	}
+	var total int64
+	for i, e := range endpoints {
+		weight := e.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += int64(weight)
+		w.cumWeights[i] = total
+	}
+	w.total = total
+	return w
+}
+
+// pick returns the Endpoint to use for the next request, chosen with
+// probability proportional to its weight.
+func (w *Workload) pick() *Endpoint {
+	if len(w.endpoints) == 1 {
+		return &w.endpoints[0]
+	}
+	r := rand.Int63n(w.total)
+	idx := sort.Search(len(w.cumWeights), func(i int) bool { return w.cumWeights[i] > r })
+	return &w.endpoints[idx]
+}
+
+// loadWorkloadFile parses a -urls file: one endpoint per line, either a bare
+// URL (defaulting to weight 1, GET) or "weight verb url", e.g.
+// "3 GET https://a/" or "1 POST https://b/". defaultBody is attached to any
+// POST/PUT endpoint that does not carry its own inline body.
+func loadWorkloadFile(path string, defaultBody []byte) (*Workload, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var endpoints []Endpoint
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		endpoint, err := parseWorkloadLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if (endpoint.Method == http.MethodPost || endpoint.Method == http.MethodPut) && endpoint.Body == nil {
+			endpoint.Body = defaultBody
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("%s: no endpoints found", path)
+	}
+	return newWorkload(endpoints), nil
+}
+
+func parseWorkloadLine(line string) (Endpoint, error) {
+	fields := strings.Fields(line)
+	switch len(fields) {
+	case 1:
+		return Endpoint{Weight: 1, Method: http.MethodGet, URL: fields[0]}, nil
+	case 3:
+		weight, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return Endpoint{}, fmt.Errorf("invalid weight %q: %w", fields[0], err)
+		}
+		return Endpoint{Weight: weight, Method: strings.ToUpper(fields[1]), URL: fields[2]}, nil
+	default:
+		return Endpoint{}, fmt.Errorf("invalid line %q, expected \"url\" or \"weight verb url\"", line)
+	}
+}
+
+// loadBody resolves the -body flag: a value starting with "@" is read from
+// the named file, otherwise it is used as a literal body.
+func loadBody(spec string) ([]byte, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(spec, "@") {
+		return ioutil.ReadFile(spec[1:])
+	}
+	return []byte(spec), nil
+}
+
+// headerList accumulates repeated -H "K: V" flags into an http.Header.
+type headerList struct {
+	http.Header
+}
+
+func newHeaderList() *headerList {
+	return &headerList{Header: make(http.Header)}
+}
+
+func (h *headerList) String() string {
+	return fmt.Sprint(h.Header)
+}
+
+func (h *headerList) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid -H value %q, expected \"K: V\"", value)
+	}
+	h.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestWorkloadPickRespectsWeights(t *testing.T) {
+	w := newWorkload([]Endpoint{
+		{Weight: 3, Method: "GET", URL: "http://a"},
+		{Weight: 1, Method: "GET", URL: "http://b"},
+	})
+
+	const trials = 10000
+	counts := make(map[string]int)
+	for i := 0; i < trials; i++ {
+		counts[w.pick().URL]++
+	}
+
+	gotRatio := float64(counts["http://a"]) / float64(counts["http://b"])
+	const wantRatio = 3.0
+	const tolerance = 0.15
+	if diff := (gotRatio - wantRatio) / wantRatio; diff > tolerance || diff < -tolerance {
+		t.Errorf("a/b pick ratio = %.2f, want ~%.2f (counts a=%d b=%d)", gotRatio, wantRatio, counts["http://a"], counts["http://b"])
+	}
+}
+
+func TestWorkloadPickSingleEndpoint(t *testing.T) {
+	w := newWorkload([]Endpoint{{Weight: 1, Method: "GET", URL: "http://only"}})
+	for i := 0; i < 10; i++ {
+		if got := w.pick().URL; got != "http://only" {
+			t.Errorf("pick() = %q, want http://only", got)
+		}
+	}
+}
+
+func TestParseWorkloadLine(t *testing.T) {
+	e, err := parseWorkloadLine("3 POST https://a/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.Weight != 3 || e.Method != "POST" || e.URL != "https://a/" {
+		t.Errorf("got %+v, want weight=3 method=POST url=https://a/", e)
+	}
+
+	e, err = parseWorkloadLine("https://b/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.Weight != 1 || e.Method != "GET" || e.URL != "https://b/" {
+		t.Errorf("got %+v, want weight=1 method=GET url=https://b/", e)
+	}
+
+	if _, err := parseWorkloadLine("bogus line here"); err == nil {
+		t.Error("expected an error for a malformed line, got nil")
+	}
+}